@@ -0,0 +1,112 @@
+package fernet
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"io"
+	"time"
+
+	"golang.org/x/crypto/hkdf"
+)
+
+const saltSize = 16
+
+// seal encrypts and authenticates plaintext with Keys[0], embedding the
+// current time so ReadState can enforce Options.MaxAge without a second
+// round trip to storage.
+func (c *CookieStorer) seal(plaintext []byte) (string, error) {
+	salt := make([]byte, saltSize)
+	if _, err := io.ReadFull(rand.Reader, salt); err != nil {
+		return "", err
+	}
+
+	gcm, err := gcmFor(c.Keys[0], salt)
+	if err != nil {
+		return "", err
+	}
+
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return "", err
+	}
+
+	issuedAt := make([]byte, 8)
+	binary.BigEndian.PutUint64(issuedAt, uint64(time.Now().UTC().Unix()))
+
+	ciphertext := gcm.Seal(nil, nonce, plaintext, issuedAt)
+
+	out := make([]byte, 0, len(salt)+len(nonce)+len(issuedAt)+len(ciphertext))
+	out = append(out, salt...)
+	out = append(out, nonce...)
+	out = append(out, issuedAt...)
+	out = append(out, ciphertext...)
+
+	return encode(out), nil
+}
+
+// open tries every key in the ring in order and returns the first
+// successful decryption. ok is false if the value is malformed or doesn't
+// authenticate under any key in the ring.
+func (c *CookieStorer) open(value string) (plaintext []byte, issuedAt time.Time, ok bool) {
+	raw, err := decode(value)
+	if err != nil {
+		return nil, time.Time{}, false
+	}
+
+	for _, key := range c.Keys {
+		if pt, at, ok := openWith(key, raw); ok {
+			return pt, at, true
+		}
+	}
+
+	return nil, time.Time{}, false
+}
+
+func openWith(key Key, raw []byte) ([]byte, time.Time, bool) {
+	if len(raw) < saltSize+8 {
+		return nil, time.Time{}, false
+	}
+
+	salt, rest := raw[:saltSize], raw[saltSize:]
+
+	gcm, err := gcmFor(key, salt)
+	if err != nil {
+		return nil, time.Time{}, false
+	}
+
+	if len(rest) < gcm.NonceSize()+8 {
+		return nil, time.Time{}, false
+	}
+
+	nonce := rest[:gcm.NonceSize()]
+	issuedAtBytes := rest[gcm.NonceSize() : gcm.NonceSize()+8]
+	ciphertext := rest[gcm.NonceSize()+8:]
+
+	plaintext, err := gcm.Open(nil, nonce, ciphertext, issuedAtBytes)
+	if err != nil {
+		return nil, time.Time{}, false
+	}
+
+	issuedAt := time.Unix(int64(binary.BigEndian.Uint64(issuedAtBytes)), 0).UTC()
+	return plaintext, issuedAt, true
+}
+
+// gcmFor derives a per-message AES-256 key from master via HKDF-SHA256
+// (using salt as the HKDF salt) and wraps it in GCM.
+func gcmFor(master Key, salt []byte) (cipher.AEAD, error) {
+	derived := make([]byte, 32)
+	kdf := hkdf.New(sha256.New, master[:], salt, []byte("authboss/clientstate/fernet"))
+	if _, err := io.ReadFull(kdf, derived); err != nil {
+		return nil, err
+	}
+
+	block, err := aes.NewCipher(derived)
+	if err != nil {
+		return nil, err
+	}
+
+	return cipher.NewGCM(block)
+}
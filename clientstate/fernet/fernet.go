@@ -0,0 +1,237 @@
+// Package fernet implements authboss.ClientStateReadWriter on top of
+// encrypted, authenticated cookies, so small apps can run fully stateless -
+// no session store, no plaintext remember-me cookie - while getting
+// stronger guarantees than gorilla/sessions' securecookie defaults (a key
+// ring for rotation, an embedded issued-at for max-age enforcement, and
+// chunking for payloads past the 4KB per-cookie limit).
+package fernet
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/volatiletech/authboss"
+)
+
+// maxCookieValueSize is kept comfortably under the ~4096 byte limit most
+// browsers impose on a cookie (name+value+attributes combined).
+const maxCookieValueSize = 3800
+
+// Key is a single AES-256 key in a CookieStorer's key ring.
+type Key [32]byte
+
+// CookieOptions configures the cookies a CookieStorer writes. There's no
+// Config.Storage field for it: authboss.Config.Storage.CookieState only
+// takes the already-constructed authboss.ClientStateReadWriter, and this
+// package imports authboss (for ClientState/ClientStateEvent), so the root
+// package can't hold a CookieOptions field without an import cycle. The app
+// builds a CookieOptions and passes it to New directly.
+type CookieOptions struct {
+	Path     string
+	Domain   string
+	SameSite http.SameSite
+	Secure   bool
+	HTTPOnly bool
+	MaxAge   time.Duration
+}
+
+// CookieStorer is a authboss.ClientStateReadWriter backed by encrypted
+// cookies instead of a session store. Every value written is sealed with
+// AES-256-GCM using a key derived (via HKDF-SHA256) from the first key in
+// Keys; every key in Keys is tried in turn when opening a cookie, so
+// rotating keys is: prepend the new key, deploy, and once MaxAge has
+// elapsed since the rotation, drop the old key.
+type CookieStorer struct {
+	Name    string
+	Keys    []Key
+	Options CookieOptions
+}
+
+// New creates a CookieStorer. keys[0] encrypts; every key is tried (in
+// order) to decrypt.
+func New(name string, opts CookieOptions, keys ...Key) (*CookieStorer, error) {
+	if len(keys) == 0 {
+		return nil, errors.New("fernet: at least one key is required")
+	}
+	if opts.MaxAge <= 0 {
+		return nil, errors.New("fernet: CookieOptions.MaxAge must be positive")
+	}
+
+	return &CookieStorer{Name: name, Keys: keys, Options: opts}, nil
+}
+
+// clientState is the authboss.ClientState implementation backing a
+// CookieStorer - just a plain map, since the whole point is that the
+// cookie already holds the full, authoritative value set.
+type clientState map[string]string
+
+func (c clientState) Get(key string) (string, bool) {
+	v, ok := c[key]
+	return v, ok
+}
+
+// ReadState reconstructs the encrypted state from the request's cookies,
+// rejecting (by returning an empty state, not an error) anything that
+// fails to decrypt or has aged past Options.MaxAge - a tampered or stale
+// cookie should look like "logged out", not produce a 500.
+func (c *CookieStorer) ReadState(r *http.Request) (authboss.ClientState, error) {
+	sealed, ok := readChunked(r, c.Name)
+	if !ok {
+		return clientState{}, nil
+	}
+
+	plaintext, issuedAt, ok := c.open(sealed)
+	if !ok || time.Since(issuedAt) > c.Options.MaxAge {
+		return clientState{}, nil
+	}
+
+	state := make(clientState)
+	if err := json.Unmarshal(plaintext, &state); err != nil {
+		return clientState{}, nil
+	}
+
+	return state, nil
+}
+
+// WriteState merges ev into the state previously produced by ReadState (or
+// a fresh empty state, for a first write) and seals + writes the result
+// back out, chunked across as many cookies as it takes.
+func (c *CookieStorer) WriteState(w http.ResponseWriter, state authboss.ClientState, ev []authboss.ClientStateEvent) error {
+	merged := make(clientState)
+	if existing, ok := state.(clientState); ok {
+		for k, v := range existing {
+			merged[k] = v
+		}
+	}
+
+	for _, e := range ev {
+		switch e.Kind {
+		case authboss.ClientStateEventPut:
+			merged[e.Key] = e.Value
+		case authboss.ClientStateEventDel:
+			delete(merged, e.Key)
+		}
+	}
+
+	if len(merged) == 0 {
+		clearChunked(w, c.Name, c.Options)
+		return nil
+	}
+
+	plaintext, err := json.Marshal(merged)
+	if err != nil {
+		return errors.Wrap(err, "fernet: failed to marshal client state")
+	}
+
+	sealed, err := c.seal(plaintext)
+	if err != nil {
+		return errors.Wrap(err, "fernet: failed to seal client state")
+	}
+
+	return writeChunked(w, c.Name, sealed, c.Options)
+}
+
+// readChunked reassembles a value previously split by writeChunked: Name
+// holds the first (and possibly only) chunk, Name.1, Name.2, ... hold the
+// rest, in order, until one is missing.
+func readChunked(r *http.Request, name string) (string, bool) {
+	cookie, err := r.Cookie(name)
+	if err != nil {
+		return "", false
+	}
+
+	value := cookie.Value
+	for i := 1; ; i++ {
+		next, err := r.Cookie(name + "." + strconv.Itoa(i))
+		if err != nil {
+			break
+		}
+		value += next.Value
+	}
+
+	return value, true
+}
+
+// maxChunksEverWritten bounds how many chunk cookies writeChunked will ever
+// produce for one value, and how many indices clearChunked checks when
+// clearing a (potentially chunked) cookie it doesn't know the prior size of.
+// writeChunked itself enforces this bound (rather than just the cleanup
+// tail below it) by refusing to write a payload that would need more.
+const maxChunksEverWritten = 8
+
+// errPayloadTooLarge is returned by writeChunked when value would need more
+// than maxChunksEverWritten cookies to store - writing it anyway would mean
+// a later, smaller write could never clear all of this write's chunks,
+// reintroducing the stale-chunk bug clearChunked/writeChunked's cleanup
+// tail exist to prevent.
+var errPayloadTooLarge = errors.New("fernet: client state is too large to fit in maxChunksEverWritten cookies")
+
+func writeChunked(w http.ResponseWriter, name, value string, opts CookieOptions) error {
+	if len(value) > maxCookieValueSize*maxChunksEverWritten {
+		return errPayloadTooLarge
+	}
+
+	i := 0
+	for ; len(value) > 0; i++ {
+		chunkName := name
+		if i > 0 {
+			chunkName = name + "." + strconv.Itoa(i)
+		}
+
+		end := maxCookieValueSize
+		if end > len(value) {
+			end = len(value)
+		}
+
+		http.SetCookie(w, cookie(chunkName, value[:end], opts))
+		value = value[end:]
+	}
+
+	// A previous, larger write may have used more chunks than this one
+	// does - without clearing them, ReadState would reassemble this
+	// write's chunks followed by stale leftovers from the old one and
+	// fail to decrypt, which looks like (and acts like) the user being
+	// logged out. Clear every higher index up to the bound we ourselves
+	// never exceed.
+	expired := opts
+	expired.MaxAge = -time.Hour
+	for ; i < maxChunksEverWritten; i++ {
+		http.SetCookie(w, cookie(name+"."+strconv.Itoa(i), "", expired))
+	}
+
+	return nil
+}
+
+// clearChunked deletes a (potentially chunked) cookie written by
+// writeChunked. It always clears the first few indices since the caller
+// has no way of knowing how many chunks a previous, larger payload used.
+func clearChunked(w http.ResponseWriter, name string, opts CookieOptions) {
+	expired := opts
+	expired.MaxAge = -time.Hour
+
+	http.SetCookie(w, cookie(name, "", expired))
+	for i := 1; i < maxChunksEverWritten; i++ {
+		http.SetCookie(w, cookie(name+"."+strconv.Itoa(i), "", expired))
+	}
+}
+
+func cookie(name, value string, opts CookieOptions) *http.Cookie {
+	return &http.Cookie{
+		Name:     name,
+		Value:    value,
+		Path:     opts.Path,
+		Domain:   opts.Domain,
+		SameSite: opts.SameSite,
+		Secure:   opts.Secure,
+		HttpOnly: opts.HTTPOnly,
+		MaxAge:   int(opts.MaxAge / time.Second),
+	}
+}
+
+func encode(b []byte) string          { return base64.RawURLEncoding.EncodeToString(b) }
+func decode(s string) ([]byte, error) { return base64.RawURLEncoding.DecodeString(s) }
@@ -0,0 +1,242 @@
+package fernet
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/volatiletech/authboss"
+)
+
+func newKey(b byte) Key {
+	var k Key
+	for i := range k {
+		k[i] = b
+	}
+	return k
+}
+
+func testOptions(maxAge time.Duration) CookieOptions {
+	return CookieOptions{Path: "/", MaxAge: maxAge}
+}
+
+// cookieJar is a minimal stand-in for a browser's persistent cookie store:
+// Set-Cookie headers with a negative MaxAge delete the matching name,
+// everything else upserts it, and Request() builds the next request from
+// whatever's left - which is the only realistic way to test behavior that
+// spans more than one request/response pair.
+type cookieJar map[string]*http.Cookie
+
+func (j cookieJar) Apply(rec *httptest.ResponseRecorder) {
+	for _, c := range rec.Result().Cookies() {
+		if c.MaxAge < 0 {
+			delete(j, c.Name)
+			continue
+		}
+		j[c.Name] = c
+	}
+}
+
+func (j cookieJar) Request() *http.Request {
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	for _, c := range j {
+		r.AddCookie(c)
+	}
+	return r
+}
+
+// applyCookies is a convenience wrapper for tests that only need a single
+// response's cookies applied to a fresh jar.
+func applyCookies(t *testing.T, rec *httptest.ResponseRecorder) *http.Request {
+	t.Helper()
+
+	jar := cookieJar{}
+	jar.Apply(rec)
+	return jar.Request()
+}
+
+func TestCookieStorerRoundTrip(t *testing.T) {
+	store, err := New("session", testOptions(time.Hour), newKey(1))
+	if err != nil {
+		t.Fatalf("New returned error: %v", err)
+	}
+
+	rec := httptest.NewRecorder()
+	empty, err := store.ReadState(httptest.NewRequest(http.MethodGet, "/", nil))
+	if err != nil {
+		t.Fatalf("ReadState on a fresh request returned error: %v", err)
+	}
+
+	err = store.WriteState(rec, empty, []authboss.ClientStateEvent{
+		{Kind: authboss.ClientStateEventPut, Key: "pid", Value: "bob"},
+	})
+	if err != nil {
+		t.Fatalf("WriteState returned error: %v", err)
+	}
+
+	state, err := store.ReadState(applyCookies(t, rec))
+	if err != nil {
+		t.Fatalf("ReadState returned error: %v", err)
+	}
+
+	if v, ok := state.Get("pid"); !ok || v != "bob" {
+		t.Errorf("state.Get(pid) = %q, %v; want \"bob\", true", v, ok)
+	}
+}
+
+func TestCookieStorerRejectsTamperedCookie(t *testing.T) {
+	store, err := New("session", testOptions(time.Hour), newKey(1))
+	if err != nil {
+		t.Fatalf("New returned error: %v", err)
+	}
+
+	rec := httptest.NewRecorder()
+	_ = store.WriteState(rec, clientState{}, []authboss.ClientStateEvent{
+		{Kind: authboss.ClientStateEventPut, Key: "pid", Value: "bob"},
+	})
+
+	req := applyCookies(t, rec)
+	c, _ := req.Cookie("session")
+	c.Value = c.Value[:len(c.Value)-2] + "xx" // flip a couple of trailing chars
+
+	tampered := httptest.NewRequest(http.MethodGet, "/", nil)
+	tampered.AddCookie(c)
+
+	state, err := store.ReadState(tampered)
+	if err != nil {
+		t.Fatalf("ReadState returned an error instead of an empty state: %v", err)
+	}
+	if _, ok := state.Get("pid"); ok {
+		t.Error("a tampered cookie decrypted successfully, it should have been rejected")
+	}
+}
+
+func TestCookieStorerKeyRotation(t *testing.T) {
+	oldKey := newKey(1)
+	newK := newKey(2)
+
+	oldStore, err := New("session", testOptions(time.Hour), oldKey)
+	if err != nil {
+		t.Fatalf("New returned error: %v", err)
+	}
+
+	rec := httptest.NewRecorder()
+	_ = oldStore.WriteState(rec, clientState{}, []authboss.ClientStateEvent{
+		{Kind: authboss.ClientStateEventPut, Key: "pid", Value: "bob"},
+	})
+
+	// The ring now has the new key first (for future writes) and the old
+	// key second (so cookies sealed before rotation keep validating).
+	rotatedStore, err := New("session", testOptions(time.Hour), newK, oldKey)
+	if err != nil {
+		t.Fatalf("New returned error: %v", err)
+	}
+
+	state, err := rotatedStore.ReadState(applyCookies(t, rec))
+	if err != nil {
+		t.Fatalf("ReadState returned error: %v", err)
+	}
+	if v, ok := state.Get("pid"); !ok || v != "bob" {
+		t.Errorf("state.Get(pid) after rotation = %q, %v; want \"bob\", true", v, ok)
+	}
+}
+
+func TestCookieStorerMaxAgeExpiry(t *testing.T) {
+	store, err := New("session", testOptions(20*time.Millisecond), newKey(1))
+	if err != nil {
+		t.Fatalf("New returned error: %v", err)
+	}
+
+	rec := httptest.NewRecorder()
+	_ = store.WriteState(rec, clientState{}, []authboss.ClientStateEvent{
+		{Kind: authboss.ClientStateEventPut, Key: "pid", Value: "bob"},
+	})
+
+	req := applyCookies(t, rec)
+	time.Sleep(40 * time.Millisecond)
+
+	state, err := store.ReadState(req)
+	if err != nil {
+		t.Fatalf("ReadState returned error: %v", err)
+	}
+	if _, ok := state.Get("pid"); ok {
+		t.Error("ReadState returned a value from a cookie older than MaxAge")
+	}
+}
+
+func TestWriteStateChunksLargePayloadsAndClearsStaleChunksOnShrink(t *testing.T) {
+	store, err := New("session", testOptions(time.Hour), newKey(1))
+	if err != nil {
+		t.Fatalf("New returned error: %v", err)
+	}
+
+	// A big payload that needs several chunk cookies.
+	big := strings.Repeat("x", maxCookieValueSize*3)
+	rec := httptest.NewRecorder()
+	err = store.WriteState(rec, clientState{}, []authboss.ClientStateEvent{
+		{Kind: authboss.ClientStateEventPut, Key: "blob", Value: big},
+	})
+	if err != nil {
+		t.Fatalf("WriteState returned error: %v", err)
+	}
+
+	cookies := rec.Result().Cookies()
+	if len(cookies) < 3 {
+		t.Fatalf("expected the large payload to be split across several cookies, got %d", len(cookies))
+	}
+
+	jar := cookieJar{}
+	jar.Apply(rec)
+
+	state, err := store.ReadState(jar.Request())
+	if err != nil {
+		t.Fatalf("ReadState returned error: %v", err)
+	}
+	if v, ok := state.Get("blob"); !ok || v != big {
+		t.Fatalf("failed to round-trip the large, chunked payload")
+	}
+
+	// Now shrink it down to something that fits in a single cookie.
+	rec2 := httptest.NewRecorder()
+	err = store.WriteState(rec2, state, []authboss.ClientStateEvent{
+		{Kind: authboss.ClientStateEventDel, Key: "blob"},
+		{Kind: authboss.ClientStateEventPut, Key: "pid", Value: "bob"},
+	})
+	if err != nil {
+		t.Fatalf("WriteState returned error: %v", err)
+	}
+
+	// The browser applies rec2's Set-Cookie headers on top of the jar it
+	// already had from the big write - including the expirations for the
+	// now-unused higher-index chunks, which is what should make this work.
+	jar.Apply(rec2)
+
+	final, err := store.ReadState(jar.Request())
+	if err != nil {
+		t.Fatalf("ReadState returned error: %v", err)
+	}
+	if v, ok := final.Get("pid"); !ok || v != "bob" {
+		t.Errorf("after shrinking the payload, state.Get(pid) = %q, %v; want \"bob\", true - got reset to an empty state instead (stale chunk bug)", v, ok)
+	}
+	if _, ok := final.Get("blob"); ok {
+		t.Error("deleted key \"blob\" is still present after shrinking")
+	}
+}
+
+func TestWriteStateRejectsPayloadsThatWouldExceedMaxChunks(t *testing.T) {
+	store, err := New("session", testOptions(time.Hour), newKey(1))
+	if err != nil {
+		t.Fatalf("New returned error: %v", err)
+	}
+
+	huge := strings.Repeat("x", maxCookieValueSize*maxChunksEverWritten*2)
+	rec := httptest.NewRecorder()
+	err = store.WriteState(rec, clientState{}, []authboss.ClientStateEvent{
+		{Kind: authboss.ClientStateEventPut, Key: "blob", Value: huge},
+	})
+	if err == nil {
+		t.Fatal("expected WriteState to reject a payload needing more than maxChunksEverWritten cookies, got nil error")
+	}
+}
@@ -53,9 +53,29 @@ type Config struct {
 		// recovery is valid for.
 		RecoverTokenDuration time.Duration
 
+		// RememberDuration controls how long a remember-me token is valid for
+		// before it must be re-established by a full login.
+		RememberDuration time.Duration
+
 		// OAuth2Providers lists all providers that can be used. See
 		// OAuthProvider documentation for more details.
 		OAuth2Providers map[string]OAuth2Provider
+
+		// OIDCIssuer is the issuer URL (also the base of the discovery
+		// document) to run authboss as an OpenID Connect identity
+		// provider. Leave empty to keep the oidcprovider module's routes
+		// disabled.
+		OIDCIssuer string
+		// OIDCKeyStore supplies the keys ID tokens are signed with.
+		// Required if OIDCIssuer is set.
+		OIDCKeyStore OIDCKeyStore
+		// OIDCClaimsMapper builds the claims embedded in ID tokens and
+		// returned from the userinfo endpoint. If nil, only the standard
+		// claims are emitted.
+		OIDCClaimsMapper OIDCClaimsMapper
+		// OIDCAllowDynamicClientRegistration enables the unauthenticated
+		// dynamic client registration endpoint (RFC 7591). Off by default.
+		OIDCAllowDynamicClientRegistration bool
 	}
 
 	Mail struct {
@@ -133,4 +153,5 @@ func (c *Config) Defaults() {
 	c.Modules.LockWindow = 5 * time.Minute
 	c.Modules.LockDuration = 5 * time.Hour
 	c.Modules.RecoverTokenDuration = time.Duration(24) * time.Hour
+	c.Modules.RememberDuration = 30 * 24 * time.Hour
 }
@@ -0,0 +1,112 @@
+package authboss
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+)
+
+// FlashKind differentiates flash messages so templates (and JSON API
+// clients) can style/handle them differently, e.g. a green banner for
+// FlashSuccess vs. a red one for FlashError.
+type FlashKind string
+
+// Flash kinds
+const (
+	FlashSuccess FlashKind = "success"
+	FlashInfo    FlashKind = "info"
+	FlashError   FlashKind = "error"
+)
+
+// Flash is a one-shot message meant to survive exactly one redirect, e.g.
+// "Check your inbox for the confirmation email" after registering.
+type Flash struct {
+	Kind    FlashKind `json:"kind"`
+	Message string    `json:"message"`
+}
+
+// sessionFlashesKey is the reserved SessionState key flashes are stashed
+// under between Flash and Flashes.
+const sessionFlashesKey = "flashes"
+
+// Flash queues a one-shot message in the user's session to be picked up by
+// the next call to Flashes, typically on the page the user's redirected to.
+func (a *Authboss) Flash(w http.ResponseWriter, r *http.Request, kind FlashKind, message string) error {
+	flashes, _ := a.loadFlashes(r)
+	flashes = append(flashes, Flash{Kind: kind, Message: message})
+	return a.saveFlashes(w, flashes)
+}
+
+// Flashes returns every flash message queued for the current user and
+// clears them, so each one is delivered exactly once.
+func (a *Authboss) Flashes(w http.ResponseWriter, r *http.Request) []Flash {
+	flashes, ok := a.loadFlashes(r)
+	if !ok || len(flashes) == 0 {
+		return nil
+	}
+
+	a.saveFlashes(w, nil)
+	return flashes
+}
+
+func (a *Authboss) loadFlashes(r *http.Request) ([]Flash, bool) {
+	raw, ok := GetSession(r, sessionFlashesKey)
+	if !ok || len(raw) == 0 {
+		return nil, false
+	}
+
+	var flashes []Flash
+	if err := json.Unmarshal([]byte(raw), &flashes); err != nil {
+		return nil, false
+	}
+
+	return flashes, true
+}
+
+func (a *Authboss) saveFlashes(w http.ResponseWriter, flashes []Flash) error {
+	if len(flashes) == 0 {
+		DelSession(w, sessionFlashesKey)
+		return nil
+	}
+
+	raw, err := json.Marshal(flashes)
+	if err != nil {
+		return err
+	}
+
+	PutSession(w, sessionFlashesKey, string(raw))
+	return nil
+}
+
+// LoadFlashesMiddleware reads any queued flashes and injects them into
+// CTXKeyData under "Flashes" so every ViewRenderer template receives
+// .Flashes without each handler having to call Flashes itself.
+func LoadFlashesMiddleware(ab *Authboss) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if flashes := ab.Flashes(w, r); len(flashes) > 0 {
+				data, _ := r.Context().Value(CTXKeyData).(HTMLData)
+				if data == nil {
+					data = HTMLData{}
+				}
+				data["Flashes"] = flashes
+
+				r = r.WithContext(context.WithValue(r.Context(), CTXKeyData, data))
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// FlashesJSON returns the queued flashes already wrapped under the stable
+// "flashes" key, so a JSON HTTPResponder can merge the result straight into
+// its response body for API clients.
+func (a *Authboss) FlashesJSON(w http.ResponseWriter, r *http.Request) map[string]interface{} {
+	flashes := a.Flashes(w, r)
+	if len(flashes) == 0 {
+		return nil
+	}
+
+	return map[string]interface{}{"flashes": flashes}
+}
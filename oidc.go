@@ -0,0 +1,24 @@
+package authboss
+
+// OIDCKeyStore supplies the asymmetric keys used to sign ID tokens issued by
+// the oidcprovider module. Implementations are expected to support key
+// rotation: Current is used to sign new tokens, All is used to publish the
+// JWKS document so tokens signed with a retiring key keep validating until
+// they naturally expire.
+type OIDCKeyStore interface {
+	// Current returns the key new ID tokens should be signed with along
+	// with the "kid" that identifies it in the JWKS document. key must be
+	// a *rsa.PrivateKey or *ecdsa.PrivateKey.
+	Current() (kid string, key interface{}, err error)
+	// All returns every public key that should be published in the JWKS
+	// document, keyed by kid. Values must be *rsa.PublicKey or
+	// *ecdsa.PublicKey.
+	All() (map[string]interface{}, error)
+}
+
+// OIDCClaimsMapper builds the set of claims to embed in an ID token (and
+// return from the userinfo endpoint) for the given user. Apps implement
+// this to expose whatever scopes/claims their clients need; authboss only
+// ever adds the standard claims (iss, sub, aud, exp, iat, nonce) on top of
+// whatever this returns.
+type OIDCClaimsMapper func(user User) map[string]interface{}
@@ -0,0 +1,43 @@
+package oidcprovider
+
+import "testing"
+
+func TestVerifyPKCE(t *testing.T) {
+	const verifier = "dBjftJeZ4CVP-mB92K27uhbUJU1p1r_wW1gFWFOEjXk"
+	const challengeS256 = "E9Melhoa2OwvFrEMTJguCHaoeK1t8URWbuGJSstw-cM"
+
+	cases := []struct {
+		name      string
+		verifier  string
+		challenge string
+		method    string
+		want      bool
+	}{
+		{"S256 match", verifier, challengeS256, "S256", true},
+		{"S256 mismatch", "wrong-verifier", challengeS256, "S256", false},
+		{"plain match", "same-value", "same-value", "plain", true},
+		{"plain mismatch", "a", "b", "plain", false},
+		{"empty method treated as plain", "same-value", "same-value", "", true},
+		{"unknown method rejected", verifier, challengeS256, "bogus", false},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			got := verifyPKCE(c.verifier, c.challenge, c.method)
+			if got != c.want {
+				t.Errorf("verifyPKCE(%q, %q, %q) = %v, want %v", c.verifier, c.challenge, c.method, got, c.want)
+			}
+		})
+	}
+}
+
+func TestValidRedirectURI(t *testing.T) {
+	client := &Client{RedirectURIs: []string{"https://app.example.com/cb", "https://app.example.com/cb2"}}
+
+	if !validRedirectURI(client, "https://app.example.com/cb") {
+		t.Error("expected a registered redirect_uri to be valid")
+	}
+	if validRedirectURI(client, "https://evil.example.com/cb") {
+		t.Error("expected an unregistered redirect_uri to be rejected")
+	}
+}
@@ -0,0 +1,35 @@
+package oidcprovider
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// Discovery serves the OpenID Connect discovery document at
+// /.well-known/openid-configuration.
+func (o *OIDCProvider) Discovery(w http.ResponseWriter, r *http.Request) {
+	issuer := o.Modules.OIDCIssuer
+	mount := issuer + o.mountPath()
+
+	doc := map[string]interface{}{
+		"issuer":                                issuer,
+		"authorization_endpoint":                mount + "/authorize",
+		"token_endpoint":                        mount + "/token",
+		"jwks_uri":                              mount + "/jwks.json",
+		"userinfo_endpoint":                     mount + "/userinfo",
+		"response_types_supported":              []string{"code", "id_token", "code id_token"},
+		"subject_types_supported":               []string{"public"},
+		"id_token_signing_alg_values_supported": []string{"RS256", "ES256"},
+		"scopes_supported":                      []string{"openid", "profile", "email"},
+		"token_endpoint_auth_methods_supported": []string{"client_secret_post", "client_secret_basic", "none"},
+		"code_challenge_methods_supported":      []string{"S256", "plain"},
+		"claims_supported":                      []string{"sub", "iss", "aud", "exp", "iat"},
+	}
+
+	if o.Modules.OIDCAllowDynamicClientRegistration {
+		doc["registration_endpoint"] = mount + "/register"
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(doc)
+}
@@ -0,0 +1,76 @@
+package oidcprovider
+
+import (
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"encoding/json"
+	"math/big"
+	"net/http"
+
+	"github.com/pkg/errors"
+)
+
+var errUnsupportedKeyType = errors.New("oidcprovider: unsupported jwk key type")
+
+// JWKS serves the JSON Web Key Set of every key authboss will currently
+// accept an ID token signature from, keyed by "kid" so relying parties can
+// keep validating tokens signed with a key that's being rotated out.
+func (o *OIDCProvider) JWKS(w http.ResponseWriter, r *http.Request) {
+	keys, err := o.Modules.OIDCKeyStore.All()
+	if err != nil {
+		o.RequestLogger(r).Errorf("oidcprovider: failed to load jwks: %+v", err)
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+
+	jwks := struct {
+		Keys []map[string]string `json:"keys"`
+	}{}
+
+	for kid, key := range keys {
+		jwk, err := toJWK(kid, key)
+		if err != nil {
+			o.RequestLogger(r).Errorf("oidcprovider: skipping unpublishable jwk %q: %+v", kid, err)
+			continue
+		}
+		jwks.Keys = append(jwks.Keys, jwk)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(jwks)
+}
+
+func toJWK(kid string, key interface{}) (map[string]string, error) {
+	switch k := key.(type) {
+	case *rsa.PublicKey:
+		return map[string]string{
+			"kty": "RSA",
+			"use": "sig",
+			"alg": "RS256",
+			"kid": kid,
+			"n":   b64Int(k.N),
+			"e":   b64Int(big.NewInt(int64(k.E))),
+		}, nil
+	case *rsa.PrivateKey:
+		return toJWK(kid, &k.PublicKey)
+	case *ecdsa.PublicKey:
+		size := (k.Curve.Params().BitSize + 7) / 8
+		x := make([]byte, size)
+		y := make([]byte, size)
+		k.X.FillBytes(x)
+		k.Y.FillBytes(y)
+		return map[string]string{
+			"kty": "EC",
+			"use": "sig",
+			"alg": "ES256",
+			"kid": kid,
+			"crv": k.Curve.Params().Name,
+			"x":   b64(x),
+			"y":   b64(y),
+		}, nil
+	case *ecdsa.PrivateKey:
+		return toJWK(kid, &k.PublicKey)
+	default:
+		return nil, errUnsupportedKeyType
+	}
+}
@@ -0,0 +1,79 @@
+package oidcprovider
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+
+	"github.com/pkg/errors"
+)
+
+// signIDToken builds a compact JWS (header.payload.signature, all
+// base64url-no-padding) over claims, signed with key. Only the two
+// algorithms mandatory-to-implement by most OIDC relying parties are
+// supported: RS256 (*rsa.PrivateKey) and ES256 (*ecdsa.PrivateKey).
+func signIDToken(kid string, key interface{}, claims map[string]interface{}) (string, error) {
+	var alg string
+	switch key.(type) {
+	case *rsa.PrivateKey:
+		alg = "RS256"
+	case *ecdsa.PrivateKey:
+		alg = "ES256"
+	default:
+		return "", errors.Errorf("oidcprovider: unsupported signing key type %T", key)
+	}
+
+	header, err := json.Marshal(map[string]string{"alg": alg, "typ": "JWT", "kid": kid})
+	if err != nil {
+		return "", errors.Wrap(err, "failed to marshal jwt header")
+	}
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		return "", errors.Wrap(err, "failed to marshal jwt claims")
+	}
+
+	signingInput := b64(header) + "." + b64(payload)
+
+	sig, err := sign(alg, key, signingInput)
+	if err != nil {
+		return "", err
+	}
+
+	return signingInput + "." + b64(sig), nil
+}
+
+func sign(alg string, key interface{}, signingInput string) ([]byte, error) {
+	sum := sha256.Sum256([]byte(signingInput))
+
+	switch alg {
+	case "RS256":
+		return rsa.SignPKCS1v15(rand.Reader, key.(*rsa.PrivateKey), crypto.SHA256, sum[:])
+	case "ES256":
+		priv := key.(*ecdsa.PrivateKey)
+		r, s, err := ecdsa.Sign(rand.Reader, priv, sum[:])
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to sign id token")
+		}
+
+		keyBytes := (priv.Curve.Params().BitSize + 7) / 8
+		sig := make([]byte, 2*keyBytes)
+		r.FillBytes(sig[:keyBytes])
+		s.FillBytes(sig[keyBytes:])
+		return sig, nil
+	default:
+		return nil, errors.Errorf("oidcprovider: unsupported signing algorithm %s", alg)
+	}
+}
+
+func b64(data []byte) string {
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func b64Int(i *big.Int) string {
+	return base64.RawURLEncoding.EncodeToString(i.Bytes())
+}
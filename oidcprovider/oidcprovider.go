@@ -0,0 +1,61 @@
+// Package oidcprovider turns an authboss-backed application into an OpenID
+// Connect identity provider: authorization and token endpoints, a JWKS
+// endpoint, userinfo, discovery, and (optionally) dynamic client
+// registration. It reuses authboss.CurrentUser for the login step and authboss.Config.Storage.Server for user and client
+// lookups, the same way the remember module reuses the server storer for
+// its own tables.
+package oidcprovider
+
+import (
+	"net/http"
+
+	"github.com/pkg/errors"
+
+	"github.com/volatiletech/authboss"
+)
+
+func init() {
+	authboss.RegisterModule("oidcprovider", &OIDCProvider{})
+}
+
+// OIDCProvider module
+type OIDCProvider struct {
+	*authboss.Authboss
+}
+
+// Init module. Routes are only mounted when
+// authboss.Config.Modules.OIDCIssuer is set, so apps that don't want to run
+// as an IdP can ignore this module entirely without extra configuration.
+func (o *OIDCProvider) Init(ab *authboss.Authboss) error {
+	o.Authboss = ab
+
+	if len(o.Modules.OIDCIssuer) == 0 {
+		return nil
+	}
+
+	if o.Modules.OIDCKeyStore == nil {
+		return errors.New("oidcprovider: Modules.OIDCKeyStore must be set when Modules.OIDCIssuer is set")
+	}
+
+	mount := o.Paths.Mount + "/oidc"
+
+	router := o.Core.Router
+	router.Get(mount+"/authorize", http.HandlerFunc(o.Authorize))
+	router.Post(mount+"/token", http.HandlerFunc(o.Token))
+	router.Get(mount+"/jwks.json", http.HandlerFunc(o.JWKS))
+	router.Get(mount+"/userinfo", http.HandlerFunc(o.Userinfo))
+	router.Post(mount+"/userinfo", http.HandlerFunc(o.Userinfo))
+	router.Get("/.well-known/openid-configuration", http.HandlerFunc(o.Discovery))
+
+	if o.Modules.OIDCAllowDynamicClientRegistration {
+		router.Post(mount+"/register", http.HandlerFunc(o.Register))
+	}
+
+	return nil
+}
+
+// mountPath returns the path this module's OIDC routes are mounted under,
+// e.g. "/auth/oidc".
+func (o *OIDCProvider) mountPath() string {
+	return o.Paths.Mount + "/oidc"
+}
@@ -0,0 +1,80 @@
+package oidcprovider
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"net/http"
+)
+
+// Register implements dynamic client registration (RFC 7591). It's only
+// mounted when authboss.Config.Modules.OIDCAllowDynamicClientRegistration
+// is true, and further requires the app's storer to opt in by implementing
+// ClientRegisterer.
+func (o *OIDCProvider) Register(w http.ResponseWriter, r *http.Request) {
+	registerer, ok := o.Storage.Server.(ClientRegisterer)
+	if !ok {
+		http.Error(w, "dynamic client registration is not supported by this server", http.StatusNotImplemented)
+		return
+	}
+
+	clients, err := ensureCanClient(o.Storage.Server)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	var body struct {
+		ClientID     string   `json:"client_id"`
+		ClientSecret string   `json:"client_secret"`
+		RedirectURIs []string `json:"redirect_uris"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		http.Error(w, "invalid_client_metadata", http.StatusBadRequest)
+		return
+	}
+
+	if len(body.ClientID) == 0 {
+		id, err := randomToken(16)
+		if err != nil {
+			http.Error(w, "server_error", http.StatusInternalServerError)
+			return
+		}
+		body.ClientID = id
+	} else if _, err := clients.LoadClient(r.Context(), body.ClientID); err != ErrClientNotFound {
+		// A caller-supplied client_id that already resolves to a client
+		// must not be honored - otherwise anyone can re-POST an existing
+		// id with a new secret/redirect_uris and hijack that client's
+		// registration. err == nil means it's already registered; any
+		// other error is a storage failure, not a confirmed-available id.
+		if err == nil {
+			http.Error(w, "invalid_client_metadata", http.StatusConflict)
+		} else {
+			http.Error(w, "server_error", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	client := &Client{
+		ID:           body.ClientID,
+		RedirectURIs: body.RedirectURIs,
+		Public:       len(body.ClientSecret) == 0,
+	}
+	if !client.Public {
+		sum := sha256.Sum256([]byte(body.ClientSecret))
+		client.SecretHash = sum[:]
+	}
+
+	if err := registerer.RegisterClient(r.Context(), client); err != nil {
+		o.RequestLogger(r).Errorf("oidcprovider: failed to register client: %+v", err)
+		http.Error(w, "server_error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	_ = json.NewEncoder(w).Encode(map[string]interface{}{
+		"client_id":     client.ID,
+		"client_secret": body.ClientSecret,
+		"redirect_uris": client.RedirectURIs,
+	})
+}
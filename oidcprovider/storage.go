@@ -0,0 +1,89 @@
+package oidcprovider
+
+import (
+	"context"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+var (
+	// ErrClientNotFound is returned by a ClientStorer when the requested
+	// client_id has no registered client.
+	ErrClientNotFound = errors.New("oidc client not found")
+	// ErrAuthRequestNotFound is returned by an AuthRequestStorer when the
+	// requested code/access token has no pending (or already consumed) request.
+	ErrAuthRequestNotFound = errors.New("oidc auth request not found")
+)
+
+// Client is a registered OIDC relying party.
+type Client struct {
+	ID           string
+	SecretHash   []byte
+	RedirectURIs []string
+	Public       bool // true for clients that can't keep a secret (SPA/mobile), forces PKCE
+}
+
+// AuthRequest is the state authboss needs to remember between the
+// authorization endpoint and the token endpoint: the pending grant created
+// by Authorize, and (once exchanged) the opaque access token handed back by
+// Token so Userinfo can look the grant back up.
+type AuthRequest struct {
+	// Code is the primary key: the authorization code before exchange, or
+	// the opaque access token after exchange.
+	Code                string
+	ClientID            string
+	PID                 string
+	RedirectURI         string
+	Scope               string
+	Nonce               string
+	CodeChallenge       string
+	CodeChallengeMethod string
+	ExpiresAt           time.Time
+}
+
+// ClientStorer loads registered OIDC clients. Apps implement this against
+// their own persistence and assign it to authboss.Config.Storage.Server
+// (the same storer that satisfies authboss.ServerStorer).
+type ClientStorer interface {
+	LoadClient(ctx context.Context, clientID string) (*Client, error)
+}
+
+// ClientRegisterer is an optional extension of ClientStorer that allows new
+// clients to be created via the dynamic client registration endpoint. It's
+// only consulted when
+// authboss.Config.Modules.OIDCAllowDynamicClientRegistration is true.
+type ClientRegisterer interface {
+	RegisterClient(ctx context.Context, client *Client) error
+}
+
+// AuthRequestStorer persists pending authorization grants and, after
+// exchange, the opaque access tokens minted for them.
+type AuthRequestStorer interface {
+	SaveAuthRequest(ctx context.Context, req *AuthRequest) error
+	// LoadAuthRequest fetches and deletes the request matching code in one
+	// operation, so a code/access token can only ever be used once.
+	LoadAuthRequest(ctx context.Context, code string) (*AuthRequest, error)
+	// PeekAuthRequest fetches the request matching code without deleting it,
+	// so callers (Userinfo) can be repeatedly called with the same access
+	// token without consuming it on first use, the way most OIDC clients
+	// expect. It still must return ErrAuthRequestNotFound for an unknown
+	// code, the same as LoadAuthRequest.
+	PeekAuthRequest(ctx context.Context, code string) (*AuthRequest, error)
+}
+
+func ensureCanClient(storer interface{}) (ClientStorer, error) {
+	s, ok := storer.(ClientStorer)
+	if !ok {
+		return nil, errors.New("oidcprovider: Storage.Server must implement oidcprovider.ClientStorer")
+	}
+	return s, nil
+}
+
+func ensureCanAuthRequest(storer interface{}) (AuthRequestStorer, error) {
+	s, ok := storer.(AuthRequestStorer)
+	if !ok {
+		return nil, errors.New("oidcprovider: Storage.Server must implement oidcprovider.AuthRequestStorer")
+	}
+	return s, nil
+}
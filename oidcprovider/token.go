@@ -0,0 +1,205 @@
+package oidcprovider
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/volatiletech/authboss"
+)
+
+// errClientAuthRequired is returned by authenticateClient when the caller
+// didn't prove they are the client the authorization code was issued to -
+// either a confidential client without a valid client_secret_post/
+// client_secret_basic, or any client_id that doesn't resolve to a client at
+// all.
+var errClientAuthRequired = errors.New("oidcprovider: client authentication failed")
+
+// Token implements the token endpoint for the authorization_code grant.
+// On success it exchanges the one-time code minted by Authorize for an
+// access token (opaque, usable at Userinfo until it expires) and a signed ID
+// token, and raises authboss.EventOIDCTokenIssued so other modules (audit
+// logging, rate limiting, ...) can observe issuance.
+func (o *OIDCProvider) Token(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		tokenError(w, "invalid_request", http.StatusBadRequest)
+		return
+	}
+
+	if r.PostForm.Get("grant_type") != "authorization_code" {
+		tokenError(w, "unsupported_grant_type", http.StatusBadRequest)
+		return
+	}
+
+	clients, err := ensureCanClient(o.Storage.Server)
+	if err != nil {
+		tokenError(w, "server_error", http.StatusInternalServerError)
+		return
+	}
+
+	client, err := o.authenticateClient(r, clients)
+	if err != nil {
+		w.Header().Set("WWW-Authenticate", `Basic realm="oidc"`)
+		tokenError(w, "invalid_client", http.StatusUnauthorized)
+		return
+	}
+
+	reqs, err := ensureCanAuthRequest(o.Storage.Server)
+	if err != nil {
+		tokenError(w, "server_error", http.StatusInternalServerError)
+		return
+	}
+
+	authReq, err := reqs.LoadAuthRequest(r.Context(), r.PostForm.Get("code"))
+	switch {
+	case err == ErrAuthRequestNotFound:
+		tokenError(w, "invalid_grant", http.StatusBadRequest)
+		return
+	case err != nil:
+		tokenError(w, "server_error", http.StatusInternalServerError)
+		return
+	}
+
+	if time.Now().UTC().After(authReq.ExpiresAt) {
+		tokenError(w, "invalid_grant", http.StatusBadRequest)
+		return
+	}
+
+	if authReq.ClientID != client.ID || authReq.RedirectURI != r.PostForm.Get("redirect_uri") {
+		tokenError(w, "invalid_grant", http.StatusBadRequest)
+		return
+	}
+
+	if len(authReq.CodeChallenge) > 0 {
+		if !verifyPKCE(r.PostForm.Get("code_verifier"), authReq.CodeChallenge, authReq.CodeChallengeMethod) {
+			tokenError(w, "invalid_grant", http.StatusBadRequest)
+			return
+		}
+	}
+
+	user, err := o.Storage.Server.Load(r.Context(), authReq.PID)
+	if err != nil {
+		tokenError(w, "server_error", http.StatusInternalServerError)
+		return
+	}
+
+	idToken, err := o.buildIDToken(authReq, user)
+	if err != nil {
+		o.RequestLogger(r).Errorf("oidcprovider: failed to sign id token: %+v", err)
+		tokenError(w, "server_error", http.StatusInternalServerError)
+		return
+	}
+
+	accessToken, err := randomToken(authCodeSize)
+	if err != nil {
+		tokenError(w, "server_error", http.StatusInternalServerError)
+		return
+	}
+
+	err = reqs.SaveAuthRequest(r.Context(), &AuthRequest{
+		Code:      accessToken,
+		ClientID:  authReq.ClientID,
+		PID:       authReq.PID,
+		Scope:     authReq.Scope,
+		ExpiresAt: time.Now().UTC().Add(accessTokenTTL),
+	})
+	if err != nil {
+		tokenError(w, "server_error", http.StatusInternalServerError)
+		return
+	}
+
+	o.Events.Raise(authboss.EventOIDCTokenIssued, w, r)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	_ = json.NewEncoder(w).Encode(map[string]interface{}{
+		"access_token": accessToken,
+		"token_type":   "Bearer",
+		"expires_in":   int(accessTokenTTL.Seconds()),
+		"id_token":     idToken,
+		"scope":        authReq.Scope,
+	})
+}
+
+func (o *OIDCProvider) buildIDToken(authReq *AuthRequest, user authboss.User) (string, error) {
+	kid, key, err := o.Modules.OIDCKeyStore.Current()
+	if err != nil {
+		return "", err
+	}
+
+	now := time.Now().UTC()
+	claims := map[string]interface{}{
+		"iss": o.Modules.OIDCIssuer,
+		"sub": user.GetPID(),
+		"aud": authReq.ClientID,
+		"iat": now.Unix(),
+		"exp": now.Add(accessTokenTTL).Unix(),
+	}
+	if len(authReq.Nonce) > 0 {
+		claims["nonce"] = authReq.Nonce
+	}
+
+	if o.Modules.OIDCClaimsMapper != nil {
+		for k, v := range o.Modules.OIDCClaimsMapper(user) {
+			claims[k] = v
+		}
+	}
+
+	return signIDToken(kid, key, claims)
+}
+
+// authenticateClient implements client_secret_basic and client_secret_post:
+// public clients (those that registered with no secret, relying on PKCE
+// instead) are identified by client_id alone, everyone else must present a
+// client_secret that hashes to the registered client's SecretHash.
+func (o *OIDCProvider) authenticateClient(r *http.Request, clients ClientStorer) (*Client, error) {
+	clientID, secret, ok := clientCredentials(r)
+	if !ok {
+		return nil, errClientAuthRequired
+	}
+
+	client, err := clients.LoadClient(r.Context(), clientID)
+	if err != nil {
+		return nil, errClientAuthRequired
+	}
+
+	if client.Public {
+		return client, nil
+	}
+
+	if len(secret) == 0 {
+		return nil, errClientAuthRequired
+	}
+
+	sum := sha256.Sum256([]byte(secret))
+	if subtle.ConstantTimeCompare(sum[:], client.SecretHash) != 1 {
+		return nil, errClientAuthRequired
+	}
+
+	return client, nil
+}
+
+// clientCredentials extracts client_id/client_secret from either HTTP Basic
+// auth (client_secret_basic) or the POST body (client_secret_post).
+func clientCredentials(r *http.Request) (id, secret string, ok bool) {
+	if id, secret, ok = r.BasicAuth(); ok {
+		return id, secret, true
+	}
+
+	id = r.PostForm.Get("client_id")
+	if len(id) == 0 {
+		return "", "", false
+	}
+
+	return id, r.PostForm.Get("client_secret"), true
+}
+
+func tokenError(w http.ResponseWriter, code string, status int) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(map[string]string{"error": code})
+}
@@ -0,0 +1,93 @@
+package oidcprovider
+
+import (
+	"context"
+	"crypto/sha256"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+type fakeClientStorer map[string]*Client
+
+func (f fakeClientStorer) LoadClient(ctx context.Context, clientID string) (*Client, error) {
+	c, ok := f[clientID]
+	if !ok {
+		return nil, ErrClientNotFound
+	}
+	return c, nil
+}
+
+func secretHash(secret string) []byte {
+	sum := sha256.Sum256([]byte(secret))
+	return sum[:]
+}
+
+func postRequest(t *testing.T, form url.Values, basicUser, basicPass string) *http.Request {
+	t.Helper()
+
+	r, err := http.NewRequest(http.MethodPost, "/oidc/token", strings.NewReader(form.Encode()))
+	if err != nil {
+		t.Fatalf("failed to build request: %v", err)
+	}
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	if len(basicUser) > 0 {
+		r.SetBasicAuth(basicUser, basicPass)
+	}
+	if err := r.ParseForm(); err != nil {
+		t.Fatalf("failed to parse form: %v", err)
+	}
+	return r
+}
+
+func TestAuthenticateClient(t *testing.T) {
+	o := &OIDCProvider{}
+
+	clients := fakeClientStorer{
+		"confidential": &Client{ID: "confidential", SecretHash: secretHash("s3cret")},
+		"public-spa":   &Client{ID: "public-spa", Public: true},
+	}
+
+	t.Run("confidential client with correct client_secret_post succeeds", func(t *testing.T) {
+		form := url.Values{"client_id": {"confidential"}, "client_secret": {"s3cret"}}
+		if _, err := o.authenticateClient(postRequest(t, form, "", ""), clients); err != nil {
+			t.Errorf("expected success, got %v", err)
+		}
+	})
+
+	t.Run("confidential client with correct client_secret_basic succeeds", func(t *testing.T) {
+		form := url.Values{}
+		if _, err := o.authenticateClient(postRequest(t, form, "confidential", "s3cret"), clients); err != nil {
+			t.Errorf("expected success, got %v", err)
+		}
+	})
+
+	t.Run("confidential client with wrong secret is rejected", func(t *testing.T) {
+		form := url.Values{"client_id": {"confidential"}, "client_secret": {"wrong"}}
+		if _, err := o.authenticateClient(postRequest(t, form, "", ""), clients); err == nil {
+			t.Error("expected an authentication error, got nil")
+		}
+	})
+
+	t.Run("confidential client with no secret at all is rejected", func(t *testing.T) {
+		form := url.Values{"client_id": {"confidential"}}
+		if _, err := o.authenticateClient(postRequest(t, form, "", ""), clients); err == nil {
+			t.Error("expected an authentication error, got nil")
+		}
+	})
+
+	t.Run("public client needs no secret", func(t *testing.T) {
+		form := url.Values{"client_id": {"public-spa"}}
+		if _, err := o.authenticateClient(postRequest(t, form, "", ""), clients); err != nil {
+			t.Errorf("expected success, got %v", err)
+		}
+	})
+
+	t.Run("unknown client is rejected", func(t *testing.T) {
+		form := url.Values{"client_id": {"does-not-exist"}, "client_secret": {"whatever"}}
+		if _, err := o.authenticateClient(postRequest(t, form, "", ""), clients); err == nil {
+			t.Error("expected an authentication error, got nil")
+		}
+	})
+}
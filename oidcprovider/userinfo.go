@@ -0,0 +1,69 @@
+package oidcprovider
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Userinfo implements the userinfo endpoint: given a valid Bearer access
+// token minted by Token, it returns the user's "sub" plus whatever
+// OIDCClaimsMapper returns for them. The token-specific ID token claims
+// (iss, aud, iat, exp, nonce) are not included.
+//
+// Access tokens are multi-use within their TTL, matching the expires_in
+// Token hands back - they're only looked up here (via PeekAuthRequest), not
+// consumed. An expired token is deleted on the request that notices it's
+// expired and rejected with invalid_token, the same as an unknown one.
+func (o *OIDCProvider) Userinfo(w http.ResponseWriter, r *http.Request) {
+	auth := r.Header.Get("Authorization")
+	if !strings.HasPrefix(auth, "Bearer ") {
+		w.Header().Set("WWW-Authenticate", `Bearer error="invalid_token"`)
+		http.Error(w, "invalid_token", http.StatusUnauthorized)
+		return
+	}
+	accessToken := strings.TrimPrefix(auth, "Bearer ")
+
+	reqs, err := ensureCanAuthRequest(o.Storage.Server)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	authReq, err := reqs.PeekAuthRequest(r.Context(), accessToken)
+	switch {
+	case err == ErrAuthRequestNotFound:
+		w.Header().Set("WWW-Authenticate", `Bearer error="invalid_token"`)
+		http.Error(w, "invalid_token", http.StatusUnauthorized)
+		return
+	case err != nil:
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+
+	if time.Now().UTC().After(authReq.ExpiresAt) {
+		if _, err := reqs.LoadAuthRequest(r.Context(), accessToken); err != nil && err != ErrAuthRequestNotFound {
+			o.RequestLogger(r).Errorf("oidcprovider: failed to delete expired access token: %+v", err)
+		}
+		w.Header().Set("WWW-Authenticate", `Bearer error="invalid_token"`)
+		http.Error(w, "invalid_token", http.StatusUnauthorized)
+		return
+	}
+
+	user, err := o.Storage.Server.Load(r.Context(), authReq.PID)
+	if err != nil {
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+
+	claims := map[string]interface{}{"sub": user.GetPID()}
+	if o.Modules.OIDCClaimsMapper != nil {
+		for k, v := range o.Modules.OIDCClaimsMapper(user) {
+			claims[k] = v
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(claims)
+}
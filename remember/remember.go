@@ -2,11 +2,13 @@
 package remember
 
 import (
-	"bytes"
 	"crypto/rand"
-	"crypto/sha512"
+	"crypto/sha256"
+	"crypto/subtle"
 	"encoding/base64"
 	"net/http"
+	"strings"
+	"time"
 
 	"github.com/pkg/errors"
 
@@ -14,7 +16,16 @@ import (
 )
 
 const (
-	nNonceSize = 32
+	// selectorSize is the number of random bytes used for the selector,
+	// the (non-secret) part of the token that's used to find the row in
+	// the database.
+	selectorSize = 16
+	// validatorSize is the number of random bytes used for the validator,
+	// the secret part of the token that's hashed and compared in
+	// constant time once the row has been found.
+	validatorSize = 32
+
+	tokenSeparator = "."
 )
 
 var (
@@ -52,19 +63,31 @@ func (r *Remember) RememberAfterAuth(w http.ResponseWriter, req *http.Request, h
 	}
 
 	user := r.Authboss.CurrentUserP(w, req)
-	hash, token, err := GenerateToken(user.GetPID())
-	if err != nil {
+	if err := r.rememberUser(w, user.GetPID()); err != nil {
 		return false, err
 	}
 
+	return false, nil
+}
+
+// rememberUser generates a fresh selector/validator pair, stores the hashed
+// validator against the selector and writes the resulting cookie.
+func (r *Remember) rememberUser(w http.ResponseWriter, pid string) error {
+	token, selector, validatorHash, err := GenerateToken()
+	if err != nil {
+		return err
+	}
+
+	expiry := time.Now().UTC().Add(r.Authboss.Config.Modules.RememberDuration)
+
 	storer := authboss.EnsureCanRemember(r.Authboss.Config.Storage.Server)
-	if err = storer.AddRememberToken(user.GetPID(), hash); err != nil {
-		return false, err
+	if err := storer.AddRememberToken(pid, selector, validatorHash, expiry); err != nil {
+		return errors.Wrap(err, "failed to save remember me token")
 	}
 
 	authboss.PutCookie(w, authboss.CookieRemember, token)
 
-	return false, nil
+	return nil
 }
 
 /*
@@ -131,9 +154,14 @@ func Middleware(ab *authboss.Authboss) func(http.Handler) http.Handler {
 // Authenticate the user using their remember cookie.
 // If the cookie proves unusable it will be deleted. A cookie
 // may be unusable for the following reasons:
-// - Can't decode the base64
-// - Invalid token format
-// - Can't find token in DB
+// - It's in the old pid;nonce format from before the selector/validator rework
+// - Can't decode the base64 of either part
+// - Can't find the selector in the DB
+// - The validator doesn't hash to the stored value
+// - The token has expired
+//
+// On every successful use the token is rotated: a fresh selector/validator
+// pair replaces the one that was just consumed.
 func Authenticate(ab *authboss.Authboss, w http.ResponseWriter, req *http.Request) error {
 	logger := ab.RequestLogger(req)
 	cookie, ok := authboss.GetCookie(req, authboss.CookieRemember)
@@ -141,42 +169,46 @@ func Authenticate(ab *authboss.Authboss, w http.ResponseWriter, req *http.Reques
 		return nil
 	}
 
-	rawToken, err := base64.URLEncoding.DecodeString(cookie)
-	if err != nil {
-		authboss.DelCookie(w, authboss.CookieRemember)
-		logger.Infof("failed to decode remember me cookie, deleting cookie")
-		return nil
-	}
-
-	index := bytes.IndexByte(rawToken, ';')
-	if index < 0 {
+	selector, validator, ok := parseToken(cookie)
+	if !ok {
 		authboss.DelCookie(w, authboss.CookieRemember)
-		logger.Infof("failed to decode remember me token, deleting cookie")
+		logger.Infof("remember me cookie was in an unrecognized or outdated format, forcing re-login")
 		return nil
 	}
 
-	pid := string(rawToken[:index])
-	sum := sha512.Sum512(rawToken)
-	hash := base64.StdEncoding.EncodeToString(sum[:])
-
 	storer := authboss.EnsureCanRemember(ab.Config.Storage.Server)
-	err = storer.UseRememberToken(pid, hash)
+	pid, validatorHash, expiry, err := storer.UseRememberToken(selector)
 	switch {
 	case err == authboss.ErrTokenNotFound:
-		logger.Infof("remember me cookie had a token that was not in storage, deleting cookie")
+		logger.Infof("remember me cookie had a selector that was not in storage, deleting cookie")
 		authboss.DelCookie(w, authboss.CookieRemember)
 		return nil
 	case err != nil:
 		return err
 	}
 
-	hash, token, err := GenerateToken(pid)
+	sum := sha256.Sum256(validator)
+	wantHash := base64.StdEncoding.EncodeToString(sum[:])
+	if subtle.ConstantTimeCompare([]byte(wantHash), []byte(validatorHash)) != 1 {
+		logger.Infof("remember me cookie failed validation, deleting cookie and revoking user's remember tokens")
+		authboss.DelCookie(w, authboss.CookieRemember)
+		return storer.DelRememberTokens(pid)
+	}
+
+	if time.Now().UTC().After(expiry) {
+		logger.Infof("remember me cookie has expired, deleting cookie")
+		authboss.DelCookie(w, authboss.CookieRemember)
+		return nil
+	}
+
+	token, newSelector, newValidatorHash, err := GenerateToken()
 	if err != nil {
 		return err
 	}
 
-	if err = storer.AddRememberToken(pid, hash); err != nil {
-		return errors.Wrap(err, "failed to save me token")
+	newExpiry := time.Now().UTC().Add(ab.Config.Modules.RememberDuration)
+	if err = storer.AddRememberToken(pid, newSelector, newValidatorHash, newExpiry); err != nil {
+		return errors.Wrap(err, "failed to save remember me token")
 	}
 
 	authboss.PutSession(w, authboss.SessionKey, pid)
@@ -202,20 +234,62 @@ func (r *Remember) AfterPasswordReset(w http.ResponseWriter, req *http.Request,
 	authboss.DelCookie(w, authboss.CookieRemember)
 
 	logger.Infof("deleting tokens and rm cookies for user %s due to password reset", pid)
+	if err := r.Authboss.Flash(w, req, authboss.FlashInfo, "Your password was changed, you'll need to log in again on your other devices."); err != nil {
+		logger.Infof("failed to queue password reset flash message: %+v", err)
+	}
 
 	return false, storer.DelRememberTokens(pid)
 }
 
-// GenerateToken creates a remember me token
-func GenerateToken(pid string) (hash string, token string, err error) {
-	rawToken := make([]byte, nNonceSize+len(pid)+1)
-	copy(rawToken, []byte(pid))
-	rawToken[len(pid)] = ';'
+// GenerateToken creates a new remember me token. It returns the cookie value
+// (token) to hand to the client as well as the selector and the sha256 hash
+// of the validator that should be persisted by the storer - the raw
+// validator itself is never stored.
+func GenerateToken() (token, selector, validatorHash string, err error) {
+	rawSelector := make([]byte, selectorSize)
+	if _, err := rand.Read(rawSelector); err != nil {
+		return "", "", "", errors.Wrap(err, "failed to create remember me selector")
+	}
+
+	rawValidator := make([]byte, validatorSize)
+	if _, err := rand.Read(rawValidator); err != nil {
+		return "", "", "", errors.Wrap(err, "failed to create remember me validator")
+	}
+
+	selector = base64.URLEncoding.EncodeToString(rawSelector)
+	sum := sha256.Sum256(rawValidator)
+
+	token = selector + tokenSeparator + base64.URLEncoding.EncodeToString(rawValidator)
+	return token, selector, base64.StdEncoding.EncodeToString(sum[:]), nil
+}
+
+// parseToken splits a remember me cookie value into its raw selector and
+// validator, returning ok = false if the cookie isn't in the current
+// selector.validator format (for example because it's a token left over
+// from before the selector/validator rework).
+func parseToken(cookie string) (selector string, validator []byte, ok bool) {
+	parts := strings.Split(cookie, tokenSeparator)
+	if len(parts) != 2 {
+		return "", nil, false
+	}
+
+	if _, err := base64.URLEncoding.DecodeString(parts[0]); err != nil {
+		return "", nil, false
+	}
 
-	if _, err := rand.Read(rawToken[len(pid)+1:]); err != nil {
-		return "", "", errors.Wrap(err, "failed to create remember me nonce")
+	rawValidator, err := base64.URLEncoding.DecodeString(parts[1])
+	if err != nil || len(rawValidator) != validatorSize {
+		return "", nil, false
 	}
 
-	sum := sha512.Sum512(rawToken)
-	return base64.StdEncoding.EncodeToString(sum[:]), base64.URLEncoding.EncodeToString(rawToken), nil
+	return parts[0], rawValidator, true
+}
+
+// PurgeExpiredTokens removes every expired remember me token from storage.
+// It does nothing on its own - it's meant to be invoked periodically by the
+// host application (e.g. from a cron job or a ticking goroutine) since
+// expired rows are otherwise only cleaned up lazily, on their next use.
+func PurgeExpiredTokens(ab *authboss.Authboss) error {
+	storer := authboss.EnsureCanRemember(ab.Config.Storage.Server)
+	return storer.PurgeExpiredRememberTokens(time.Now().UTC())
 }
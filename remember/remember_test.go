@@ -0,0 +1,56 @@
+package remember
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"testing"
+)
+
+func TestGenerateTokenRoundTrip(t *testing.T) {
+	token, selector, validatorHash, err := GenerateToken()
+	if err != nil {
+		t.Fatalf("GenerateToken returned error: %v", err)
+	}
+
+	gotSelector, validator, ok := parseToken(token)
+	if !ok {
+		t.Fatalf("parseToken(%q) failed to parse a token we just generated", token)
+	}
+	if gotSelector != selector {
+		t.Errorf("selector in token = %q, want %q", gotSelector, selector)
+	}
+	if len(validator) != validatorSize {
+		t.Errorf("validator length = %d, want %d", len(validator), validatorSize)
+	}
+
+	sum := sha256.Sum256(validator)
+	if base64.StdEncoding.EncodeToString(sum[:]) != validatorHash {
+		t.Errorf("hash of validator from token does not match the hash GenerateToken returned for storage")
+	}
+}
+
+func TestParseTokenRejectsLegacyFormat(t *testing.T) {
+	// The pre-rework format was base64("pid;nonce") - a single base64 blob
+	// with no "." separator.
+	legacy := base64.URLEncoding.EncodeToString([]byte("someuser;thisisnotaselectorvalidatorpair"))
+
+	if _, _, ok := parseToken(legacy); ok {
+		t.Errorf("parseToken accepted a legacy-format cookie, it should force re-login instead")
+	}
+}
+
+func TestParseTokenRejectsMalformed(t *testing.T) {
+	cases := []string{
+		"",
+		"onlyoneparthere",
+		"not-base64-!!!." + base64.URLEncoding.EncodeToString(make([]byte, validatorSize)),
+		base64.URLEncoding.EncodeToString([]byte("selector")) + "." + "short",
+		base64.URLEncoding.EncodeToString([]byte("selector")) + "." + base64.URLEncoding.EncodeToString(make([]byte, validatorSize)) + "." + "extra",
+	}
+
+	for _, c := range cases {
+		if _, _, ok := parseToken(c); ok {
+			t.Errorf("parseToken(%q) = ok, want rejection", c)
+		}
+	}
+}